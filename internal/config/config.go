@@ -4,41 +4,135 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config app settings
 type Config struct {
-	GitLabToken   string `json:"gitlab_token"`
-	RootGroupPath string `json:"root_group_path"`
-	BaseURL       string `json:"gitlab_base_url"`
+	GitLabToken   string `json:"gitlab_token" yaml:"gitlab_token"`
+	RootGroupPath string `json:"root_group_path" yaml:"root_group_path"`
+	BaseURL       string `json:"gitlab_base_url" yaml:"gitlab_base_url"`
+
+	// DryRun, when true, makes the ruler compute and log the protection plan
+	// for every project without issuing any create/update/delete request.
+	DryRun bool `json:"dry_run" yaml:"dry_run"`
+
+	// Workers bounds how many projects are processed concurrently; subgroup traversal is not bounded by it.
+	Workers int `json:"workers" yaml:"workers"`
+	// RequestsPerSecond caps the rate of GitLab API calls shared across all workers.
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second"`
+
+	// Rules is the ordered list of branch-protection policies; for every branch, the
+	// first rule whose BranchPattern matches wins. A branch matching no rule is left alone.
+	Rules []Rule `json:"rules" yaml:"rules"`
+
+	// ProjectInclude, if non-empty, restricts the ruler to projects whose path_with_namespace
+	// matches at least one of these globs.
+	ProjectInclude []string `json:"project_include,omitempty" yaml:"project_include,omitempty"`
+	// ProjectExclude skips projects whose path_with_namespace matches any of these globs.
+	ProjectExclude []string `json:"project_exclude,omitempty" yaml:"project_exclude,omitempty"`
+	// GroupExclude skips subgroups (and everything under them) whose full path matches any of these globs.
+	GroupExclude []string `json:"group_exclude,omitempty" yaml:"group_exclude,omitempty"`
+
+	// ReportPath, if set, makes the ruler stream an audit record of every action it took
+	// (or, in dry-run mode, would take) to this file.
+	ReportPath string `json:"report_path,omitempty" yaml:"report_path,omitempty"`
+	// ReportFormat selects the report encoding: "json" (default) or "csv".
+	ReportFormat string `json:"report_format,omitempty" yaml:"report_format,omitempty"`
+
+	// ListenAddr is the address the "serve" subcommand's webhook server listens on.
+	ListenAddr string `json:"listen_addr,omitempty" yaml:"listen_addr,omitempty"`
+	// WebhookSecretToken, if set, must match the X-Gitlab-Token header of every webhook
+	// request the "serve" subcommand receives; requests with a missing or mismatched
+	// header are rejected.
+	WebhookSecretToken string `json:"webhook_secret_token,omitempty" yaml:"webhook_secret_token,omitempty"`
+}
+
+// Rule is a single branch-protection policy matched against a project's branches by BranchPattern,
+// a glob such as "main", "release/*" or "develop".
+type Rule struct {
+	BranchPattern string `json:"branch_pattern" yaml:"branch_pattern"`
 
-	PushAccessLevel  int `json:"push_access_level"`
-	MergeAccessLevel int `json:"merge_access_level"`
+	// PushAccessLevel and MergeAccessLevel are pointers so an explicit 0 (NoPermissions,
+	// e.g. to lock a role out and grant access only via AllowedToPush/AllowedToMerge) can be
+	// told apart from "omitted", which defaults to Developer (30).
+	PushAccessLevel  *int `json:"push_access_level,omitempty" yaml:"push_access_level,omitempty"`
+	MergeAccessLevel *int `json:"merge_access_level,omitempty" yaml:"merge_access_level,omitempty"`
+
+	// UnprotectAccessLevel, AllowForcePush and CodeOwnerApprovalRequired mirror the
+	// GitLab protected-branch fields of the same name; nil/omitted leaves GitLab's default.
+	UnprotectAccessLevel      *int  `json:"unprotect_access_level,omitempty" yaml:"unprotect_access_level,omitempty"`
+	AllowForcePush            *bool `json:"allow_force_push,omitempty" yaml:"allow_force_push,omitempty"`
+	CodeOwnerApprovalRequired *bool `json:"code_owner_approval_required,omitempty" yaml:"code_owner_approval_required,omitempty"`
+
+	// AllowedToPush and AllowedToMerge grant push/merge access to specific users or
+	// groups in addition to (or instead of) the role-based access levels above.
+	AllowedToPush  []AccessEntry `json:"allowed_to_push,omitempty" yaml:"allowed_to_push,omitempty"`
+	AllowedToMerge []AccessEntry `json:"allowed_to_merge,omitempty" yaml:"allowed_to_merge,omitempty"`
 }
 
-// LoadConfig reads и parses JSON-file config
+// AccessEntry grants branch access to a specific user or group rather than a whole role level.
+type AccessEntry struct {
+	UserID      *int `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	GroupID     *int `json:"group_id,omitempty" yaml:"group_id,omitempty"`
+	AccessLevel *int `json:"access_level,omitempty" yaml:"access_level,omitempty"`
+}
+
+// LoadConfig reads и parses a JSON or YAML policy config file, picked by its extension
+// (.yaml/.yml for YAML, anything else for JSON).
 func LoadConfig(path string) (*Config, error) {
-	cfgFile, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("open config file %q: %w", path, err)
 	}
-	defer cfgFile.Close()
 
 	var cfg Config
-	if err = json.NewDecoder(cfgFile).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("parse config file: %w", err)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err = yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse config file: %w", err)
+		}
+	default:
+		if err = json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse config file: %w", err)
+		}
 	}
 
 	if cfg.GitLabToken == "" || cfg.RootGroupPath == "" || cfg.BaseURL == "" {
 		return nil, fmt.Errorf("one of config variables is empty")
 	}
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("config must declare at least one rule")
+	}
+	for i, rule := range cfg.Rules {
+		if rule.BranchPattern == "" {
+			return nil, fmt.Errorf("rule %d: branch_pattern is empty", i)
+		}
+		if rule.PushAccessLevel == nil {
+			cfg.Rules[i].PushAccessLevel = intPtr(30)
+		}
+		if rule.MergeAccessLevel == nil {
+			cfg.Rules[i].MergeAccessLevel = intPtr(30)
+		}
+	}
 
-	if cfg.PushAccessLevel == 0 {
-		cfg.PushAccessLevel = 30
+	if cfg.Workers == 0 {
+		cfg.Workers = 5
+	}
+	if cfg.RequestsPerSecond == 0 {
+		cfg.RequestsPerSecond = 5
 	}
-	if cfg.MergeAccessLevel == 0 {
-		cfg.MergeAccessLevel = 30
+	if cfg.ReportFormat == "" {
+		cfg.ReportFormat = "json"
+	}
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = ":8080"
 	}
 
 	return &cfg, nil
 }
+
+func intPtr(v int) *int { return &v }
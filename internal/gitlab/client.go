@@ -1,307 +1,549 @@
 package gitlab
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"strconv"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"gitlab-branch-ruler/internal/report"
 )
 
-// Client GitLab API wrapper
+// Group, Project and Branch are re-exported so callers don't need to import go-gitlab directly.
+type Group = gitlab.Group
+type Project = gitlab.Project
+type Branch = gitlab.Branch
+
+// AccessEntry grants push/merge access to a specific user or group instead of a whole role level.
+type AccessEntry = gitlab.BranchPermissionOptions
+
+// Client is a thin facade over go-gitlab, adding the ruler's dry-run planning and
+// bounded-concurrency traversal on top of the vendor client.
 type Client struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	gl      *gitlab.Client
+	limiter *rate.Limiter
 
-	pushAccessLevel  int
-	mergeAccessLevel int
+	dryRun  bool
+	workers int
 }
 
-// NewClient creates new client
-func NewClient(baseURL, token string, pushLevel, mergeLevel int) *Client {
-	return &Client{
-		baseURL:          baseURL + "/api/v4",
-		token:            token,
-		httpClient:       &http.Client{},
-		pushAccessLevel:  pushLevel,
-		mergeAccessLevel: mergeLevel,
+// NewClient creates a new client backed by go-gitlab.
+func NewClient(baseURL, token string, dryRun bool, workers int, requestsPerSecond float64) (*Client, error) {
+	gl, err := gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("create go-gitlab client: %w", err)
 	}
-}
 
-func (c *Client) doRequest(method, path string, params url.Values, body io.Reader) (*http.Response, error) {
-	uri := c.baseURL + path
-	if params != nil {
-		uri += "?" + params.Encode()
-	}
-	req, err := http.NewRequest(method, uri, body)
-	if err != nil {
-		return nil, err
+	if workers <= 0 {
+		workers = 1
 	}
-	req.Header.Set("Private-Token", c.token)
-	if body != nil {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = 5
 	}
-	return c.httpClient.Do(req)
-}
 
-// Structs for JSON decoding
-type Group struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	return &Client{
+		gl:      gl,
+		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), int(requestsPerSecond)+1),
+		dryRun:  dryRun,
+		workers: workers,
+	}, nil
 }
 
-type Project struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	DefaultBranch string `json:"default_branch"`
+// wait blocks until the shared rate limiter admits another GitLab API call.
+func (c *Client) wait() {
+	_ = c.limiter.Wait(context.Background())
 }
 
 // GetGroup returns group details by ID or URL-encoded path
 func (c *Client) GetGroup(idOrPath string) (*Group, error) {
-	resp, err := c.doRequest("GET", "/groups/"+url.PathEscape(idOrPath), nil, nil)
+	c.wait()
+	group, _, err := c.gl.Groups.GetGroup(idOrPath, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("get group %q: %w", idOrPath, err)
 	}
-	defer resp.Body.Close()
+	return group, nil
+}
 
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody))
-	}
+// ListSubgroups returns all subgroups
+func (c *Client) ListSubgroups(groupID string) ([]*Group, error) {
+	var allSubgroups []*Group
+	opt := &gitlab.ListSubGroupsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+
+	for {
+		c.wait()
+		page, resp, err := c.gl.Groups.ListSubGroups(groupID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("list subgroups of %q: %w", groupID, err)
+		}
+		allSubgroups = append(allSubgroups, page...)
 
-	var group Group
-	if err = json.NewDecoder(resp.Body).Decode(&group); err != nil {
-		return nil, err
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
 	}
 
-	return &group, nil
+	return allSubgroups, nil
 }
 
-// ProcessGroup iterates through projects and subgroups
-func ProcessGroup(glClient *Client, groupID, groupName string) {
-	projectList, err := glClient.ListProjects(groupID)
-	if err != nil {
-		log.Printf("ERROR: get projects of group: %s (ID=%s): %v", groupName, groupID, err)
-	} else {
-		for _, project := range projectList {
-			if project.DefaultBranch == "" {
-				continue
-			}
+// ListProjects returns all group projects
+func (c *Client) ListProjects(groupID string) ([]*Project, error) {
+	var allProjects []*Project
+	opt := &gitlab.ListGroupProjectsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
 
-			if err = glClient.EnsureBranchProtection(project.ID, project.DefaultBranch); err != nil {
-				log.Printf("ERROR: project: %s (ID=%d): %v", project.Name, project.ID, err)
-			}
+	for {
+		c.wait()
+		page, resp, err := c.gl.Groups.ListGroupProjects(groupID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("list projects of %q: %w", groupID, err)
 		}
-	}
+		allProjects = append(allProjects, page...)
 
-	subgroupList, err := glClient.ListSubgroups(groupID)
-	if err != nil {
-		log.Printf("ERROR: get subgroups of group: %s (ID=%s): %v", groupName, groupID, err)
-		return
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
 	}
 
-	for _, subgroup := range subgroupList {
-		log.Printf("Entering subgroup: %s (ID=%d)", subgroup.Name, subgroup.ID)
-		ProcessGroup(glClient, strconv.Itoa(subgroup.ID), subgroup.Name)
-	}
+	return allProjects, nil
 }
 
-// ListSubgroups returns all subgroups
-func (c *Client) ListSubgroups(groupID string) ([]Group, error) {
-	var allSubgroups []Group
-	page := 1
+// ListBranches returns all branches of a project
+func (c *Client) ListBranches(projectID int) ([]*Branch, error) {
+	var allBranches []*Branch
+	opt := &gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+
 	for {
-		params := url.Values{"per_page": {"100"}, "page": {strconv.Itoa(page)}}
-		resp, err := c.doRequest("GET", "/groups/"+url.PathEscape(groupID)+"/subgroups", params, nil)
+		c.wait()
+		page, resp, err := c.gl.Branches.ListBranches(projectID, opt)
 		if err != nil {
-			return nil, err
-		}
-
-		var pageSubgroups []Group
-		if err = json.NewDecoder(resp.Body).Decode(&pageSubgroups); err != nil {
-			resp.Body.Close()
-			return nil, err
+			return nil, fmt.Errorf("list branches of project %d: %w", projectID, err)
 		}
-		resp.Body.Close()
+		allBranches = append(allBranches, page...)
 
-		if len(pageSubgroups) == 0 {
+		if resp.NextPage == 0 {
 			break
 		}
+		opt.Page = resp.NextPage
+	}
 
-		allSubgroups = append(allSubgroups, pageSubgroups...)
+	return allBranches, nil
+}
 
-		if resp.Header.Get("X-Next-Page") == "" {
-			break
-		}
+// GetProject returns project details by numeric ID.
+func (c *Client) GetProject(projectID int) (*Project, error) {
+	c.wait()
+	project, _, err := c.gl.Projects.GetProject(projectID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get project %d: %w", projectID, err)
+	}
+	return project, nil
+}
+
+// Action describes what EnsureBranchProtection did (or, in dry-run mode, would do) for a branch.
+type Action string
 
-		page++
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionRecreated Action = "recreated"
+	ActionNoOp      Action = "no-op"
+	ActionSkipped   Action = "skipped"
+	ActionError     Action = "error"
+)
+
+// Summary aggregates the actions taken (or planned) while walking a group tree. Safe for concurrent use.
+type Summary struct {
+	mu sync.Mutex
+
+	Created   int
+	Updated   int
+	Recreated int
+	NoOp      int
+	Skipped   int
+	Errored   int
+}
+
+func (s *Summary) record(action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch action {
+	case ActionCreated:
+		s.Created++
+	case ActionUpdated:
+		s.Updated++
+	case ActionRecreated:
+		s.Recreated++
+	case ActionNoOp:
+		s.NoOp++
+	case ActionSkipped:
+		s.Skipped++
 	}
+}
 
-	return allSubgroups, nil
+func (s *Summary) recordError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Errored++
 }
 
-// ListProjects returns all group projects
-func (c *Client) ListProjects(groupID string) ([]Project, error) {
-	var allProjects []Project
-	page := 1
-	for {
-		params := url.Values{"per_page": {"100"}, "page": {strconv.Itoa(page)}}
-		resp, err := c.doRequest("GET", "/groups/"+url.PathEscape(groupID)+"/projects", params, nil)
+// Changed reports whether any project was (or, in dry-run mode, would be) created, updated or recreated.
+func (s *Summary) Changed() bool {
+	return s.Created > 0 || s.Updated > 0 || s.Recreated > 0
+}
+
+// ProcessGroup walks the group tree, evaluating every project's branches against policy with
+// at most glClient.workers projects in flight (subgroup traversal itself is not bounded by
+// glClient.workers), and returns a summary of the actions
+// taken plus the aggregate of every per-project error. If reporter is non-nil, every branch
+// evaluated is streamed to it as an audit record.
+func ProcessGroup(glClient *Client, groupID, groupName string, policy Policy, reporter *report.Writer) (*Summary, error) {
+	summary := &Summary{}
+
+	var (
+		mu   sync.Mutex
+		errs *multierror.Error
+	)
+	addErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = multierror.Append(errs, err)
+	}
+
+	// leaves and recursion use separate errgroups: leaves are bounded to glClient.workers
+	// concurrent project evaluations, while recursion into subgroups is unbounded. Sharing one
+	// SetLimit group between them would deadlock once every slot is held by a subgroup-walk
+	// goroutine blocked trying to acquire a slot for its own projects/children — none could
+	// ever free. Recursion itself stays cheap (it's just ListSubgroups/ListProjects calls,
+	// already throttled by the shared rate limiter), so leaving it unbounded is safe.
+	leaves := new(errgroup.Group)
+	leaves.SetLimit(glClient.workers)
+	groups := new(errgroup.Group)
+
+	var walk func(groupID, groupName string)
+	walk = func(groupID, groupName string) {
+		projectList, err := glClient.ListProjects(groupID)
 		if err != nil {
-			return nil, err
+			addErr(fmt.Errorf("get projects of group %s (ID=%s): %w", groupName, groupID, err))
+		} else {
+			for _, project := range projectList {
+				project := project
+				if !policy.projectAllowed(project.PathWithNamespace) {
+					summary.record(ActionSkipped)
+					writeSkippedEntry(reporter, project, "excluded by project_include/project_exclude")
+					continue
+				}
+
+				leaves.Go(func() error {
+					processProject(glClient, project, policy, summary, addErr, reporter)
+					return nil
+				})
+			}
 		}
 
-		var pageProjects []Project
-		if err = json.NewDecoder(resp.Body).Decode(&pageProjects); err != nil {
-			resp.Body.Close()
-			return nil, err
+		subgroupList, err := glClient.ListSubgroups(groupID)
+		if err != nil {
+			addErr(fmt.Errorf("get subgroups of group %s (ID=%s): %w", groupName, groupID, err))
+			return
 		}
-		resp.Body.Close()
 
-		if len(pageProjects) == 0 {
-			break
+		for _, subgroup := range subgroupList {
+			subgroup := subgroup
+			if policy.groupExcluded(subgroup.FullPath) {
+				log.Printf("Skipping excluded subgroup: %s (ID=%d)", subgroup.Name, subgroup.ID)
+				continue
+			}
+
+			groups.Go(func() error {
+				log.Printf("Entering subgroup: %s (ID=%d)", subgroup.Name, subgroup.ID)
+				walk(strconv.Itoa(subgroup.ID), subgroup.Name)
+				return nil
+			})
 		}
+	}
 
-		allProjects = append(allProjects, pageProjects...)
+	walk(groupID, groupName)
+	_ = groups.Wait()
+	_ = leaves.Wait()
 
-		if resp.Header.Get("X-Next-Page") == "" {
-			break
-		}
+	return summary, errs.ErrorOrNil()
+}
+
+// ProcessProject evaluates a single project's branches against policy and enforces whatever
+// rule matches. Unlike ProcessGroup, it neither lists nor walks subgroups, making it suitable
+// for callers (e.g. the webhook server) that already know which project changed.
+func ProcessProject(glClient *Client, project *Project, policy Policy, reporter *report.Writer) (*Summary, error) {
+	summary := &Summary{}
 
-		page++
+	if !policy.projectAllowed(project.PathWithNamespace) {
+		summary.record(ActionSkipped)
+		writeSkippedEntry(reporter, project, "excluded by project_include/project_exclude")
+		return summary, nil
 	}
 
-	return allProjects, nil
-}
+	var (
+		mu   sync.Mutex
+		errs *multierror.Error
+	)
+	addErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = multierror.Append(errs, err)
+	}
 
-// Structs for JSON encoding
-type protectPayload struct {
-	Name           string              `json:"name,omitempty"`
-	AllowedToPush  []accessLevelHolder `json:"allowed_to_push"`
-	AllowedToMerge []accessLevelHolder `json:"allowed_to_merge"`
-}
+	processProject(glClient, project, policy, summary, addErr, reporter)
 
-type accessLevelHolder struct {
-	AccessLevel int `json:"access_level"`
+	return summary, errs.ErrorOrNil()
 }
 
-// EnsureBranchProtection sets branch protection for “branchName”
-func (c *Client) EnsureBranchProtection(projectID int, branchName string) error {
-	payload := protectPayload{
-		Name:           branchName,
-		AllowedToPush:  []accessLevelHolder{{c.pushAccessLevel}},
-		AllowedToMerge: []accessLevelHolder{{c.mergeAccessLevel}},
+// writeSkippedEntry records a project-level skip (e.g. excluded by policy before any branch is
+// even listed) so the report still accounts for every project visited.
+func writeSkippedEntry(reporter *report.Writer, project *Project, reason string) {
+	if reporter == nil {
+		return
+	}
+	entry := report.Entry{
+		ProjectID:   project.ID,
+		ProjectPath: project.PathWithNamespace,
+		Action:      string(ActionSkipped),
+		Error:       reason,
 	}
-	bodyBytes, err := json.Marshal(payload)
+	if err := reporter.Write(entry); err != nil {
+		log.Printf("ERROR: write report entry for project %d: %v", project.ID, err)
+	}
+}
+
+// processProject evaluates every branch of project against policy and enforces whatever rule matches,
+// streaming an audit record per evaluated branch to reporter (if non-nil).
+func processProject(glClient *Client, project *Project, policy Policy, summary *Summary, addErr func(error), reporter *report.Writer) {
+	branches, err := glClient.ListBranches(project.ID)
 	if err != nil {
-		return fmt.Errorf("marshal payload: %w", err)
+		summary.recordError()
+		addErr(fmt.Errorf("project %s (ID=%d): %w", project.Name, project.ID, err))
+		if reporter != nil {
+			entry := report.Entry{
+				ProjectID:   project.ID,
+				ProjectPath: project.PathWithNamespace,
+				Action:      string(ActionError),
+				Error:       err.Error(),
+			}
+			if repErr := reporter.Write(entry); repErr != nil {
+				log.Printf("ERROR: write report entry for project %d: %v", project.ID, repErr)
+			}
+		}
+		return
 	}
 
-	postURL := fmt.Sprintf("%s/projects/%d/protected_branches", c.baseURL, projectID)
-	patchURL := fmt.Sprintf("%s/projects/%d/protected_branches/%s", c.baseURL, projectID, url.PathEscape(branchName))
+	for _, branch := range branches {
+		rule, ok := policy.matchRule(branch.Name)
+		if !ok {
+			summary.record(ActionSkipped)
+			if reporter != nil {
+				entry := report.Entry{
+					ProjectID:   project.ID,
+					ProjectPath: project.PathWithNamespace,
+					Branch:      branch.Name,
+					Action:      string(ActionSkipped),
+				}
+				if repErr := reporter.Write(entry); repErr != nil {
+					log.Printf("ERROR: write report entry for project %d branch %q: %v", project.ID, branch.Name, repErr)
+				}
+			}
+			continue
+		}
 
-	doJSON := func(method, url string, data []byte) (int, []byte, error) {
-		req, err := http.NewRequest(method, url, bytes.NewReader(data))
-		if err != nil {
-			return 0, nil, err
+		result, err := glClient.EnsureBranchProtection(project.ID, branch.Name, rule)
+
+		entry := report.Entry{
+			ProjectID:     project.ID,
+			ProjectPath:   project.PathWithNamespace,
+			Branch:        branch.Name,
+			PreviousPush:  result.PreviousPush,
+			PreviousMerge: result.PreviousMerge,
+			NewPush:       int(rule.PushAccessLevel),
+			NewMerge:      int(rule.MergeAccessLevel),
+			Action:        string(result.Action),
 		}
-		req.Header.Set("Private-Token", c.token)
-		req.Header.Set("Content-Type", "application/json")
 
-		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			return 0, nil, err
+			summary.recordError()
+			entry.Action = string(ActionError)
+			entry.Error = err.Error()
+			addErr(fmt.Errorf("project %s (ID=%d): branch %q: %w", project.Name, project.ID, branch.Name, err))
+		} else {
+			summary.record(result.Action)
 		}
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
 
-		return resp.StatusCode, respBody, nil
+		if reporter != nil {
+			if repErr := reporter.Write(entry); repErr != nil {
+				log.Printf("ERROR: write report entry for project %d branch %q: %v", project.ID, branch.Name, repErr)
+			}
+		}
 	}
+}
+
+// Result is the outcome of evaluating (and, outside dry-run mode, enforcing) one branch's protection.
+type Result struct {
+	Action        Action
+	PreviousPush  int
+	PreviousMerge int
+}
 
-	status, respBody, err := doJSON("POST", postURL, bodyBytes)
+// EnsureBranchProtection enforces rule on branchName, or, in dry-run mode, computes and logs
+// the plan without issuing any create/update/delete request.
+func (c *Client) EnsureBranchProtection(projectID int, branchName string, rule Rule) (Result, error) {
+	current, err := c.getProtection(projectID, branchName)
 	if err != nil {
-		return err
+		return Result{}, err
 	}
 
-	switch status {
-	case http.StatusCreated:
-		return nil
-	case http.StatusConflict:
-	default:
-		return fmt.Errorf("create %d: %s", status, respBody)
+	result := Result{}
+	if current != nil {
+		result.PreviousPush = accessLevelOf(current.PushAccessLevels)
+		result.PreviousMerge = accessLevelOf(current.MergeAccessLevels)
 	}
 
-	status, respBody, err = doJSON("PATCH", patchURL, bodyBytes)
+	if c.dryRun {
+		result.Action, err = c.planBranchProtection(projectID, branchName, current, rule)
+	} else {
+		result.Action, err = c.applyBranchProtection(projectID, branchName, current, rule)
+	}
 	if err != nil {
-		return err
+		return Result{}, err
 	}
-	if status < 400 {
-		ok, err := c.protectionHasLevel(projectID, branchName, c.pushAccessLevel)
-		if err != nil {
-			return err
-		}
-		if ok {
-			return nil
+
+	return result, nil
+}
+
+func accessLevelOf(levels []*gitlab.BranchAccessDescription) int {
+	if len(levels) == 0 {
+		return 0
+	}
+	return int(levels[0].AccessLevel)
+}
+
+// getProtection fetches the current protection of “branchName”, returning nil if it is unprotected.
+func (c *Client) getProtection(projectID int, branchName string) (*gitlab.ProtectedBranch, error) {
+	c.wait()
+	pb, resp, err := c.gl.ProtectedBranches.GetProtectedBranch(projectID, branchName)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
 		}
+		return nil, fmt.Errorf("get protection of %q: %w", branchName, err)
 	}
+	return pb, nil
+}
 
-	// 4) если PATCH вернул ошибку или уровни не обновились — удаляем и создаём заново
-	return c.deleteAndRecreateProtection(postURL, patchURL, bodyBytes)
+// matches reports whether the currently protected branch already satisfies rule.
+func matches(pb *gitlab.ProtectedBranch, rule Rule) bool {
+	if !hasAccessLevel(pb.PushAccessLevels, rule.PushAccessLevel) {
+		return false
+	}
+	if !hasAccessLevel(pb.MergeAccessLevels, rule.MergeAccessLevel) {
+		return false
+	}
+	if rule.AllowForcePush != nil && pb.AllowForcePush != *rule.AllowForcePush {
+		return false
+	}
+	if rule.CodeOwnerApprovalRequired != nil && pb.CodeOwnerApprovalRequired != *rule.CodeOwnerApprovalRequired {
+		return false
+	}
+	if rule.UnprotectAccessLevel != nil && !hasAccessLevel(pb.UnprotectAccessLevels, *rule.UnprotectAccessLevel) {
+		return false
+	}
+	return true
 }
 
-// protectionHasLevel ensure a branch has access level
-func (c *Client) protectionHasLevel(projectID int, branchName string, level int) (bool, error) {
-	getURL := fmt.Sprintf("%s/projects/%d/protected_branches/%s", c.baseURL, projectID, url.PathEscape(branchName))
-	req, err := http.NewRequest("GET", getURL, nil)
-	if err != nil {
-		return false, err
+func hasAccessLevel(levels []*gitlab.BranchAccessDescription, want gitlab.AccessLevelValue) bool {
+	for _, level := range levels {
+		if level.AccessLevel == want {
+			return true
+		}
 	}
+	return false
+}
 
-	req.Header.Set("Private-Token", c.token)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return false, err
+// planBranchProtection computes the action EnsureBranchProtection would take for “branchName”
+// under rule and logs it as a structured plan line, without touching protection state.
+func (c *Client) planBranchProtection(projectID int, branchName string, current *gitlab.ProtectedBranch, rule Rule) (Action, error) {
+	if current == nil {
+		log.Printf("PLAN: project %d: create %q (push=%d merge=%d)",
+			projectID, branchName, rule.PushAccessLevel, rule.MergeAccessLevel)
+		return ActionCreated, nil
 	}
 
-	data, _ := io.ReadAll(resp.Body)
-	resp.Body.Close()
+	if matches(current, rule) {
+		log.Printf("PLAN: project %d: %q already matches policy, no-op", projectID, branchName)
+		return ActionNoOp, nil
+	}
 
-	pattern := fmt.Sprintf(`"access_level":%d`, level)
-	return bytes.Contains(data, []byte(pattern)), nil
+	log.Printf("PLAN: project %d: recreate %q with policy (push=%d merge=%d)",
+		projectID, branchName, rule.PushAccessLevel, rule.MergeAccessLevel)
+	return ActionRecreated, nil
 }
 
-// deleteAndRecreateProtection hard recreate branch protection
-func (c *Client) deleteAndRecreateProtection(postURL, delURL string, bodyBytes []byte) error {
-	req, _ := http.NewRequest("DELETE", delURL, nil)
-	req.Header.Set("Private-Token", c.token)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
+// applyBranchProtection sets branch protection for “branchName” to rule.
+func (c *Client) applyBranchProtection(projectID int, branchName string, current *gitlab.ProtectedBranch, rule Rule) (Action, error) {
+	opt := protectOptions(branchName, rule)
+
+	if current == nil {
+		c.wait()
+		if _, _, err := c.gl.ProtectedBranches.ProtectRepositoryBranches(projectID, opt); err != nil {
+			return "", fmt.Errorf("create protection for %q: %w", branchName, err)
+		}
+		return ActionCreated, nil
 	}
 
-	data, _ := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("delete %d: %s", resp.StatusCode, data)
+	if matches(current, rule) {
+		return ActionNoOp, nil
 	}
 
-	req2, _ := http.NewRequest("POST", postURL, bytes.NewReader(bodyBytes))
-	req2.Header.Set("Private-Token", c.token)
-	req2.Header.Set("Content-Type", "application/json")
-	resp2, err := c.httpClient.Do(req2)
-	if err != nil {
-		return err
+	// GitLab doesn't support changing a protected branch's access levels in place:
+	// unprotect and re-create with the desired policy.
+	c.wait()
+	if _, err := c.gl.ProtectedBranches.UnprotectRepositoryBranches(projectID, branchName); err != nil {
+		return "", fmt.Errorf("unprotect %q: %w", branchName, err)
+	}
+
+	c.wait()
+	if _, _, err := c.gl.ProtectedBranches.ProtectRepositoryBranches(projectID, opt); err != nil {
+		return "", fmt.Errorf("re-create protection for %q: %w", branchName, err)
+	}
+	return ActionRecreated, nil
+}
+
+// protectOptions builds the go-gitlab payload for “branchName” from rule.
+func protectOptions(branchName string, rule Rule) *gitlab.ProtectRepositoryBranchesOptions {
+	opt := &gitlab.ProtectRepositoryBranchesOptions{
+		Name:             gitlab.String(branchName),
+		PushAccessLevel:  gitlab.AccessLevel(rule.PushAccessLevel),
+		MergeAccessLevel: gitlab.AccessLevel(rule.MergeAccessLevel),
 	}
 
-	data2, _ := io.ReadAll(resp2.Body)
-	resp2.Body.Close()
-	if resp2.StatusCode >= 400 {
-		return fmt.Errorf("re-create %d: %s", resp2.StatusCode, data2)
+	if rule.UnprotectAccessLevel != nil {
+		opt.UnprotectAccessLevel = gitlab.AccessLevel(*rule.UnprotectAccessLevel)
+	}
+	if rule.AllowForcePush != nil {
+		opt.AllowForcePush = rule.AllowForcePush
+	}
+	if rule.CodeOwnerApprovalRequired != nil {
+		opt.CodeOwnerApprovalRequired = rule.CodeOwnerApprovalRequired
+	}
+	if len(rule.AllowedToPush) > 0 {
+		opt.AllowedToPush = &rule.AllowedToPush
+	}
+	if len(rule.AllowedToMerge) > 0 {
+		opt.AllowedToMerge = &rule.AllowedToMerge
 	}
 
-	return nil
+	return opt
 }
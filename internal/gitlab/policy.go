@@ -0,0 +1,64 @@
+package gitlab
+
+import (
+	"path"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// Rule is a single branch-protection policy. Policy.matchRule evaluates rules in order and
+// applies the first one whose BranchPattern matches a branch name.
+type Rule struct {
+	BranchPattern string
+
+	PushAccessLevel  gitlab.AccessLevelValue
+	MergeAccessLevel gitlab.AccessLevelValue
+
+	UnprotectAccessLevel      *gitlab.AccessLevelValue
+	AllowForcePush            *bool
+	CodeOwnerApprovalRequired *bool
+	AllowedToPush             []*AccessEntry
+	AllowedToMerge            []*AccessEntry
+}
+
+// Policy is the ordered list of rules a group tree is evaluated against, plus the
+// project/group filters that decide which projects and subgroups are visited at all.
+type Policy struct {
+	Rules []Rule
+
+	ProjectInclude []string
+	ProjectExclude []string
+	GroupExclude   []string
+}
+
+// matchRule returns the first rule whose BranchPattern matches branchName.
+func (p Policy) matchRule(branchName string) (Rule, bool) {
+	for _, rule := range p.Rules {
+		if matched, _ := path.Match(rule.BranchPattern, branchName); matched {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// projectAllowed reports whether pathWithNamespace passes the include/exclude filters.
+func (p Policy) projectAllowed(pathWithNamespace string) bool {
+	if len(p.ProjectInclude) > 0 && !matchesAny(p.ProjectInclude, pathWithNamespace) {
+		return false
+	}
+	return !matchesAny(p.ProjectExclude, pathWithNamespace)
+}
+
+// groupExcluded reports whether groupFullPath matches any of the GroupExclude globs.
+func (p Policy) groupExcluded(groupFullPath string) bool {
+	return matchesAny(p.GroupExclude, groupFullPath)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package gitlab
+
+import (
+	"testing"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestPolicyMatchRule(t *testing.T) {
+	policy := Policy{
+		Rules: []Rule{
+			{BranchPattern: "main", PushAccessLevel: 40},
+			{BranchPattern: "release/*", PushAccessLevel: 30},
+			{BranchPattern: "*", PushAccessLevel: 0},
+		},
+	}
+
+	tests := []struct {
+		branch    string
+		wantMatch bool
+		wantPush  gitlab.AccessLevelValue
+	}{
+		{branch: "main", wantMatch: true, wantPush: 40},
+		{branch: "release/1.0", wantMatch: true, wantPush: 30},
+		{branch: "develop", wantMatch: true, wantPush: 0},
+	}
+
+	for _, tt := range tests {
+		rule, ok := policy.matchRule(tt.branch)
+		if ok != tt.wantMatch {
+			t.Errorf("matchRule(%q) ok = %v, want %v", tt.branch, ok, tt.wantMatch)
+			continue
+		}
+		if ok && rule.PushAccessLevel != tt.wantPush {
+			t.Errorf("matchRule(%q) PushAccessLevel = %v, want %v", tt.branch, rule.PushAccessLevel, tt.wantPush)
+		}
+	}
+}
+
+func TestPolicyMatchRuleFirstMatchWins(t *testing.T) {
+	policy := Policy{
+		Rules: []Rule{
+			{BranchPattern: "release/*", PushAccessLevel: 40},
+			{BranchPattern: "release/1.0", PushAccessLevel: 30},
+		},
+	}
+
+	rule, ok := policy.matchRule("release/1.0")
+	if !ok {
+		t.Fatal("matchRule(\"release/1.0\") = false, want true")
+	}
+	if rule.PushAccessLevel != 40 {
+		t.Errorf("PushAccessLevel = %v, want 40 (first rule should win)", rule.PushAccessLevel)
+	}
+}
+
+func TestPolicyMatchRuleNoMatch(t *testing.T) {
+	policy := Policy{Rules: []Rule{{BranchPattern: "main"}}}
+
+	if _, ok := policy.matchRule("develop"); ok {
+		t.Error("matchRule(\"develop\") = true, want false")
+	}
+}
+
+func TestPolicyProjectAllowed(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		path   string
+		want   bool
+	}{
+		{
+			name:   "no filters allows everything",
+			policy: Policy{},
+			path:   "group/project",
+			want:   true,
+		},
+		{
+			name:   "include filter excludes non-matching paths",
+			policy: Policy{ProjectInclude: []string{"group/allowed-*"}},
+			path:   "group/other",
+			want:   false,
+		},
+		{
+			name:   "include filter allows matching paths",
+			policy: Policy{ProjectInclude: []string{"group/allowed-*"}},
+			path:   "group/allowed-1",
+			want:   true,
+		},
+		{
+			name:   "exclude filter wins over a path not otherwise restricted",
+			policy: Policy{ProjectExclude: []string{"group/blocked"}},
+			path:   "group/blocked",
+			want:   false,
+		},
+		{
+			name:   "exclude filter applies even when path matches include",
+			policy: Policy{ProjectInclude: []string{"group/*"}, ProjectExclude: []string{"group/blocked"}},
+			path:   "group/blocked",
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.projectAllowed(tt.path); got != tt.want {
+				t.Errorf("projectAllowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyGroupExcluded(t *testing.T) {
+	policy := Policy{GroupExclude: []string{"org/archived-*"}}
+
+	if !policy.groupExcluded("org/archived-team") {
+		t.Error("groupExcluded(\"org/archived-team\") = false, want true")
+	}
+	if policy.groupExcluded("org/active-team") {
+		t.Error("groupExcluded(\"org/active-team\") = true, want false")
+	}
+}
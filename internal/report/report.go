@@ -0,0 +1,113 @@
+// Package report streams machine-readable audit records of the actions the ruler
+// took (or, in dry-run mode, would take) so a run stays auditable for compliance.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Entry is one audit record: the action taken for a single project branch, including
+// its protection state before and after.
+type Entry struct {
+	ProjectID     int    `json:"project_id"`
+	ProjectPath   string `json:"project_path"`
+	Branch        string `json:"branch"`
+	PreviousPush  int    `json:"previous_push_access_level"`
+	PreviousMerge int    `json:"previous_merge_access_level"`
+	NewPush       int    `json:"new_push_access_level"`
+	NewMerge      int    `json:"new_merge_access_level"`
+	Action        string `json:"action"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Format is a report output format
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// Writer streams Entry records to an underlying writer one at a time, so reporting
+// scales to orgs with thousands of projects without buffering everything in memory.
+// Write is safe for concurrent use by the caller's worker pool.
+type Writer struct {
+	mu sync.Mutex
+
+	format         Format
+	w              io.Writer
+	csvWriter      *csv.Writer
+	wroteCSVHeader bool
+}
+
+var csvHeader = []string{
+	"project_id", "project_path", "branch",
+	"previous_push_access_level", "previous_merge_access_level",
+	"new_push_access_level", "new_merge_access_level",
+	"action", "error",
+}
+
+// NewWriter creates a Writer that streams Entry records to w in the given format.
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	switch format {
+	case FormatJSON:
+		return &Writer{format: format, w: w}, nil
+	case FormatCSV:
+		return &Writer{format: format, w: w, csvWriter: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// Write appends entry to the report, flushing immediately so a run that is interrupted
+// midway still leaves a usable file behind.
+func (rw *Writer) Write(entry Entry) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	switch rw.format {
+	case FormatJSON:
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal report entry: %w", err)
+		}
+		data = append(data, '\n')
+		if _, err = rw.w.Write(data); err != nil {
+			return fmt.Errorf("write report entry: %w", err)
+		}
+		return nil
+
+	case FormatCSV:
+		if !rw.wroteCSVHeader {
+			if err := rw.csvWriter.Write(csvHeader); err != nil {
+				return fmt.Errorf("write report header: %w", err)
+			}
+			rw.wroteCSVHeader = true
+		}
+
+		record := []string{
+			strconv.Itoa(entry.ProjectID),
+			entry.ProjectPath,
+			entry.Branch,
+			strconv.Itoa(entry.PreviousPush),
+			strconv.Itoa(entry.PreviousMerge),
+			strconv.Itoa(entry.NewPush),
+			strconv.Itoa(entry.NewMerge),
+			entry.Action,
+			entry.Error,
+		}
+		if err := rw.csvWriter.Write(record); err != nil {
+			return fmt.Errorf("write report entry: %w", err)
+		}
+		rw.csvWriter.Flush()
+		return rw.csvWriter.Error()
+
+	default:
+		return fmt.Errorf("unknown report format %q", rw.format)
+	}
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriterJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSON)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	entry := Entry{
+		ProjectID:     42,
+		ProjectPath:   "group/project",
+		Branch:        "main",
+		PreviousPush:  30,
+		PreviousMerge: 30,
+		NewPush:       40,
+		NewMerge:      40,
+		Action:        "updated",
+	}
+	if err = w.Write(entry); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+
+	var got Entry
+	if err = json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got != entry {
+		t.Errorf("round-tripped entry = %+v, want %+v", got, entry)
+	}
+}
+
+func TestWriterCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	entries := []Entry{
+		{ProjectID: 1, ProjectPath: "group/a", Branch: "main", Action: "created"},
+		{ProjectID: 2, ProjectPath: "group/b", Branch: "main", Action: "error", Error: "boom"},
+	}
+	for _, e := range entries {
+		if err = w.Write(e); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != len(entries)+1 {
+		t.Fatalf("got %d lines, want %d (1 header + %d rows)", len(lines), len(entries)+1, len(entries))
+	}
+	if lines[0] != strings.Join(csvHeader, ",") {
+		t.Errorf("header = %q, want %q", lines[0], strings.Join(csvHeader, ","))
+	}
+	if !strings.Contains(lines[2], "boom") {
+		t.Errorf("row 2 = %q, want it to contain the error message", lines[2])
+	}
+}
+
+func TestWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, Format("xml")); err == nil {
+		t.Error("NewWriter with an unknown format: got nil error, want one")
+	}
+}
+
+func TestWriterConcurrentWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_ = w.Write(Entry{ProjectID: i, ProjectPath: "group/project", Branch: "main", Action: "no-op"})
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != writers+1 {
+		t.Errorf("got %d lines, want %d (1 header + %d rows)", len(lines), writers+1, writers)
+	}
+}
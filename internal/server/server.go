@@ -0,0 +1,152 @@
+// Package server runs an HTTP server that enforces branch-protection policy in near-real-time
+// as GitLab System Hook / Project Hook events arrive, instead of requiring a cron-driven full
+// sweep of the group tree.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	glruler "gitlab-branch-ruler/internal/gitlab"
+	"gitlab-branch-ruler/internal/report"
+)
+
+// Server listens for webhook events naming a single project and runs glruler.ProcessProject
+// against it, sharing the same Policy evaluator as the CLI's full-sweep mode.
+type Server struct {
+	glClient    *glruler.Client
+	policy      glruler.Policy
+	secretToken string
+	reporter    *report.Writer
+
+	httpServer *http.Server
+}
+
+// New creates a Server that will listen on addr once ListenAndServe is called.
+func New(addr string, glClient *glruler.Client, policy glruler.Policy, secretToken string, reporter *report.Writer) *Server {
+	s := &Server{
+		glClient:    glClient,
+		policy:      policy,
+		secretToken: secretToken,
+		reporter:    reporter,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	s.httpServer = &http.Server{Addr: addr, Handler: mux}
+
+	return s
+}
+
+// ListenAndServe serves webhook requests until ctx is canceled, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return s.httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+// constantTimeEquals compares a and b in constant time, avoiding a timing side-channel on the
+// webhook secret token.
+func constantTimeEquals(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// hookEvent is the subset of GitLab System Hook / Project Hook payload fields needed to
+// resolve the affected project, across project_create, project_transfer and push events.
+type hookEvent struct {
+	EventName  string `json:"event_name"`
+	ObjectKind string `json:"object_kind"`
+	ProjectID  int    `json:"project_id"`
+	Ref        string `json:"ref"`
+	Project    *struct {
+		ID            int    `json:"id"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// routeEvent decides whether event is one the ruler should act on, across
+// project_create, project_transfer and push (only when it targets the project's
+// default branch) — everything else (issues, merge requests, etc.) is ignored.
+func routeEvent(event hookEvent) (kind string, relevant bool) {
+	kind = event.EventName
+	if kind == "" {
+		kind = event.ObjectKind
+	}
+
+	switch kind {
+	case "project_create", "project_transfer":
+		return kind, true
+	case "push":
+		return kind, event.Project != nil && event.Ref == "refs/heads/"+event.Project.DefaultBranch
+	default:
+		return kind, false
+	}
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.secretToken != "" && !constantTimeEquals(r.Header.Get("X-Gitlab-Token"), s.secretToken) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	var event hookEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	kind, relevant := routeEvent(event)
+	if !relevant {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	projectID := event.ProjectID
+	if projectID == 0 && event.Project != nil {
+		projectID = event.Project.ID
+	}
+	if projectID == 0 {
+		http.Error(w, "missing project_id", http.StatusBadRequest)
+		return
+	}
+
+	project, err := s.glClient.GetProject(projectID)
+	if err != nil {
+		log.Printf("ERROR: get project %d: %v", projectID, err)
+		http.Error(w, "get project failed", http.StatusInternalServerError)
+		return
+	}
+
+	summary, procErr := glruler.ProcessProject(s.glClient, project, s.policy, s.reporter)
+	if procErr != nil {
+		log.Printf("ERROR: enforce policy on project %s (ID=%d): %v", project.Name, project.ID, procErr)
+	}
+	log.Printf("Webhook %s: project %s (ID=%d): created=%d updated=%d recreated=%d no-op=%d skipped=%d errored=%d",
+		kind, project.Name, project.ID, summary.Created, summary.Updated, summary.Recreated, summary.NoOp, summary.Skipped, summary.Errored)
+
+	w.WriteHeader(http.StatusOK)
+}
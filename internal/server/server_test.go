@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteEvent(t *testing.T) {
+	defaultBranchProject := &struct {
+		ID            int    `json:"id"`
+		DefaultBranch string `json:"default_branch"`
+	}{ID: 1, DefaultBranch: "main"}
+
+	tests := []struct {
+		name         string
+		event        hookEvent
+		wantKind     string
+		wantRelevant bool
+	}{
+		{
+			name:         "project_create is always relevant",
+			event:        hookEvent{EventName: "project_create"},
+			wantKind:     "project_create",
+			wantRelevant: true,
+		},
+		{
+			name:         "project_transfer is always relevant",
+			event:        hookEvent{ObjectKind: "project_transfer"},
+			wantKind:     "project_transfer",
+			wantRelevant: true,
+		},
+		{
+			name:         "push to the default branch is relevant",
+			event:        hookEvent{ObjectKind: "push", Ref: "refs/heads/main", Project: defaultBranchProject},
+			wantKind:     "push",
+			wantRelevant: true,
+		},
+		{
+			name:         "push to a non-default branch is not relevant",
+			event:        hookEvent{ObjectKind: "push", Ref: "refs/heads/feature", Project: defaultBranchProject},
+			wantKind:     "push",
+			wantRelevant: false,
+		},
+		{
+			name:         "push with no project info is not relevant",
+			event:        hookEvent{ObjectKind: "push", Ref: "refs/heads/main"},
+			wantKind:     "push",
+			wantRelevant: false,
+		},
+		{
+			name:         "an unrelated event kind is not relevant",
+			event:        hookEvent{ObjectKind: "issue"},
+			wantKind:     "issue",
+			wantRelevant: false,
+		},
+		{
+			name:         "event_name takes precedence over object_kind",
+			event:        hookEvent{EventName: "project_create", ObjectKind: "push"},
+			wantKind:     "project_create",
+			wantRelevant: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, relevant := routeEvent(tt.event)
+			if kind != tt.wantKind || relevant != tt.wantRelevant {
+				t.Errorf("routeEvent(%+v) = (%q, %v), want (%q, %v)", tt.event, kind, relevant, tt.wantKind, tt.wantRelevant)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookRejectsWrongSecretToken(t *testing.T) {
+	s := &Server{secretToken: "correct-token"}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{}`))
+	req.Header.Set("X-Gitlab-Token", "wrong-token")
+	rec := httptest.NewRecorder()
+
+	s.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleWebhookIgnoresIrrelevantEvent(t *testing.T) {
+	s := &Server{}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"object_kind":"issue"}`))
+	rec := httptest.NewRecorder()
+
+	s.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandleWebhookInvalidPayload(t *testing.T) {
+	s := &Server{}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`not json`))
+	rec := httptest.NewRecorder()
+
+	s.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	s := &Server{}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+
+	s.handleHealthz(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != "ok" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "ok")
+	}
+}
@@ -1,24 +1,61 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
 	"strconv"
-	
+	"syscall"
+
+	"github.com/xanzy/go-gitlab"
+
 	"gitlab-branch-ruler/internal/config"
-	"gitlab-branch-ruler/internal/gitlab"
+	glruler "gitlab-branch-ruler/internal/gitlab"
+	"gitlab-branch-ruler/internal/report"
+	"gitlab-branch-ruler/internal/server"
 )
 
 func main() {
-	cfgPath := flag.String("config", "config.json", "path to config file")
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		runServe(args[1:])
+		return
+	}
+	runSweep(args)
+}
+
+// runSweep performs a single full walk of the root group, enforcing policy on every project.
+func runSweep(args []string) {
+	fs := flag.NewFlagSet("gitlab-branch-ruler", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "path to config file")
+	dryRun := fs.Bool("dry-run", false, "compute and log the protection plan without making any changes")
+	reportPath := fs.String("report", "", "path to write an audit report of every action taken (overrides config)")
+	reportFormat := fs.String("report-format", "", "report format: json or csv (overrides config, default json)")
+	_ = fs.Parse(args)
 
 	cfg, err := config.LoadConfig(*cfgPath)
 	if err != nil {
 		log.Fatalf("FATAL: load config: %v", err)
 	}
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	if *reportPath != "" {
+		cfg.ReportPath = *reportPath
+	}
+	if *reportFormat != "" {
+		cfg.ReportFormat = *reportFormat
+	}
 
-	glClient := gitlab.NewClient(cfg.BaseURL, cfg.GitLabToken, cfg.PushAccessLevel, cfg.MergeAccessLevel)
+	reporter, closeReport := openReporter(cfg)
+	defer closeReport()
+
+	glClient, err := glruler.NewClient(cfg.BaseURL, cfg.GitLabToken, cfg.DryRun, cfg.Workers, cfg.RequestsPerSecond)
+	if err != nil {
+		log.Fatalf("FATAL: create GitLab client: %v", err)
+	}
 
 	rootGroup, err := glClient.GetGroup(cfg.RootGroupPath)
 	if err != nil {
@@ -26,6 +63,130 @@ func main() {
 	}
 
 	log.Printf("Processing group: %s (ID=%d)", rootGroup.Name, rootGroup.ID)
-	gitlab.ProcessGroup(glClient, strconv.Itoa(rootGroup.ID), rootGroup.Name)
+	summary, procErr := glruler.ProcessGroup(glClient, strconv.Itoa(rootGroup.ID), rootGroup.Name, policy(cfg), reporter)
 	log.Printf("Finished with group: %s (ID=%d)", rootGroup.Name, rootGroup.ID)
+
+	log.Printf("Summary: created=%d updated=%d recreated=%d no-op=%d skipped=%d errored=%d",
+		summary.Created, summary.Updated, summary.Recreated, summary.NoOp, summary.Skipped, summary.Errored)
+
+	if procErr != nil {
+		log.Printf("ERROR: %v", procErr)
+	}
+
+	if procErr != nil || (cfg.DryRun && summary.Changed()) {
+		os.Exit(1)
+	}
+}
+
+// runServe starts an HTTP server that enforces policy on projects as GitLab webhook events
+// arrive, rather than requiring a cron-driven call to runSweep.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "path to config file")
+	addr := fs.String("addr", "", "address to listen on (overrides config, default :8080)")
+	secretToken := fs.String("secret-token", "", "secret token required in the X-Gitlab-Token header (overrides config)")
+	reportPath := fs.String("report", "", "path to write an audit report of every action taken (overrides config)")
+	reportFormat := fs.String("report-format", "", "report format: json or csv (overrides config, default json)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		log.Fatalf("FATAL: load config: %v", err)
+	}
+	if *addr != "" {
+		cfg.ListenAddr = *addr
+	}
+	if *secretToken != "" {
+		cfg.WebhookSecretToken = *secretToken
+	}
+	if *reportPath != "" {
+		cfg.ReportPath = *reportPath
+	}
+	if *reportFormat != "" {
+		cfg.ReportFormat = *reportFormat
+	}
+
+	reporter, closeReport := openReporter(cfg)
+	defer closeReport()
+
+	glClient, err := glruler.NewClient(cfg.BaseURL, cfg.GitLabToken, cfg.DryRun, cfg.Workers, cfg.RequestsPerSecond)
+	if err != nil {
+		log.Fatalf("FATAL: create GitLab client: %v", err)
+	}
+
+	srv := server.New(cfg.ListenAddr, glClient, policy(cfg), cfg.WebhookSecretToken, reporter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Listening for GitLab webhook events on %s", cfg.ListenAddr)
+	if err = srv.ListenAndServe(ctx); err != nil {
+		log.Fatalf("FATAL: server: %v", err)
+	}
+	log.Print("Server shut down gracefully")
+}
+
+// openReporter opens cfg.ReportPath, if set, and returns a Writer streaming to it plus a
+// close function that is always safe to defer, even when no report was configured.
+func openReporter(cfg *config.Config) (*report.Writer, func()) {
+	if cfg.ReportPath == "" {
+		return nil, func() {}
+	}
+
+	reportFile, err := os.Create(cfg.ReportPath)
+	if err != nil {
+		log.Fatalf("FATAL: create report file: %v", err)
+	}
+
+	reporter, err := report.NewWriter(reportFile, report.Format(cfg.ReportFormat))
+	if err != nil {
+		log.Fatalf("FATAL: create report writer: %v", err)
+	}
+
+	return reporter, func() { _ = reportFile.Close() }
+}
+
+// policy translates the config's rules and project/group filters into the gitlab package's
+// evaluation types.
+func policy(cfg *config.Config) glruler.Policy {
+	p := glruler.Policy{
+		ProjectInclude: cfg.ProjectInclude,
+		ProjectExclude: cfg.ProjectExclude,
+		GroupExclude:   cfg.GroupExclude,
+	}
+
+	for _, rule := range cfg.Rules {
+		p.Rules = append(p.Rules, glruler.Rule{
+			BranchPattern:             rule.BranchPattern,
+			PushAccessLevel:           gitlab.AccessLevelValue(*rule.PushAccessLevel),
+			MergeAccessLevel:          gitlab.AccessLevelValue(*rule.MergeAccessLevel),
+			UnprotectAccessLevel:      accessLevelPtr(rule.UnprotectAccessLevel),
+			AllowForcePush:            rule.AllowForcePush,
+			CodeOwnerApprovalRequired: rule.CodeOwnerApprovalRequired,
+			AllowedToPush:             accessEntries(rule.AllowedToPush),
+			AllowedToMerge:            accessEntries(rule.AllowedToMerge),
+		})
+	}
+
+	return p
+}
+
+func accessLevelPtr(level *int) *gitlab.AccessLevelValue {
+	if level == nil {
+		return nil
+	}
+	value := gitlab.AccessLevelValue(*level)
+	return &value
+}
+
+func accessEntries(entries []config.AccessEntry) []*glruler.AccessEntry {
+	var out []*glruler.AccessEntry
+	for _, entry := range entries {
+		out = append(out, &glruler.AccessEntry{
+			UserID:      entry.UserID,
+			GroupID:     entry.GroupID,
+			AccessLevel: accessLevelPtr(entry.AccessLevel),
+		})
+	}
+	return out
 }